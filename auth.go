@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func homeDir() string {
+	usr, err := user.Current()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return usr.HomeDir
+}
+
+// getTokenFromWeb uses Config to request a Token.
+// It returns the retrieved Token.
+func tokenFromWeb(config *oauth2.Config) *oauth2.Token {
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	log.Printf("Go to the following link in your browser then type the authorization code: \n%v\n", authURL)
+
+	var code string
+	if _, err := fmt.Scan(&code); err != nil {
+		log.Fatalf("Unable to read authorization code %v", err)
+	}
+
+	tok, err := config.Exchange(oauth2.NoContext, code)
+	if err != nil {
+		log.Fatalf("Unable to retrieve token from web %v", err)
+	}
+	return tok
+}
+
+// tokenFromFile retrieves a Token from a given file path.
+// It returns the retrieved Token and any read error encountered.
+func loadToken(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(t)
+	return t, err
+}
+
+// saveToken uses a file path to create a file and store the
+// token in it.
+func saveToken(file string, token *oauth2.Token) {
+	log.Printf("Saving credential file to: %s\n", file)
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Fatalf("Unable to cache oauth token: %v", err)
+	}
+	defer f.Close()
+	json.NewEncoder(f).Encode(token)
+}
+
+// getClient uses a Context and Config to retrieve a Token
+// then generate a Client. It returns the generated Client.
+func getClient() *calendar.Service {
+
+	home := homeDir()
+	basename := filepath.Base(os.Args[0])
+
+	cs, err := ioutil.ReadFile(filepath.Join(filepath.Dir(os.Args[0]), "client_secret.json"))
+	if err != nil {
+		cs, err = ioutil.ReadFile(filepath.Join(home, ".credentials", "client_secret.json"))
+	}
+	if err != nil {
+		log.Fatalf("Unable to read client secret file: %v", err)
+	}
+
+	config, err := google.ConfigFromJSON(cs, calendar.CalendarScope)
+	if err != nil {
+		log.Fatalf("Unable to parse client secret file to config: %v", err)
+	}
+
+	tokenCacheDir := filepath.Join(home, ".credentials")
+	cacheFile := filepath.Join(tokenCacheDir, basename+".json")
+	tok, err := loadToken(cacheFile)
+	if err != nil {
+		os.MkdirAll(tokenCacheDir, 0700)
+		tok = tokenFromWeb(config)
+		saveToken(cacheFile, tok)
+	}
+
+	srv, err := calendar.New(config.Client(context.Background(), tok))
+	if err != nil {
+		log.Fatalf("Unable to construct calendar Client %v", err)
+	}
+	return srv
+}
@@ -0,0 +1,112 @@
+package calc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// BatchRow is one user's consolidated figures for a team report. Err is set
+// instead of the other fields when Compute failed for that user.
+type BatchRow struct {
+	User                string
+	FTE                 float64
+	Accrued             float64
+	Spent               float64
+	Balance             float64
+	ProjectedEoYBalance float64
+	Err                 error
+}
+
+// ComputeBatch runs c.Compute for every user in users, using at most
+// concurrency workers, and returns one BatchRow per user in the same order.
+// A 403 rateLimitExceeded response from the Calendar API is retried with
+// exponential backoff rather than failing the row outright. eoy is the date
+// each report's balance is projected forward to (see Report.ProjectedBalance).
+func ComputeBatch(ctx context.Context, c *Config, users []string, concurrency int, eoy time.Time) []BatchRow {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	rows := make([]BatchRow, len(users))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				rows[idx] = computeRow(ctx, c, users[idx], eoy)
+			}
+		}()
+	}
+	for idx := range users {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return rows
+}
+
+func computeRow(ctx context.Context, c *Config, user string, eoy time.Time) BatchRow {
+	report, err := computeWithRetry(ctx, c, user)
+	if err != nil {
+		return BatchRow{User: user, Err: err}
+	}
+	return BatchRow{
+		User:                user,
+		FTE:                 report.FTE,
+		Accrued:             report.Accrued(),
+		Spent:               report.Spent(),
+		Balance:             report.Balance(),
+		ProjectedEoYBalance: report.ProjectedBalance(eoy),
+	}
+}
+
+// rateLimitRetries is how many times computeWithRetry will retry a
+// rateLimitExceeded response before giving up.
+const rateLimitRetries = 5
+
+// computeWithRetry calls c.Compute, retrying with exponential backoff
+// (starting at 500ms) whenever the Calendar API answers 403
+// rateLimitExceeded or userRateLimitExceeded, honouring Google's guidance
+// for that error.
+func computeWithRetry(ctx context.Context, c *Config, user string) (*Report, error) {
+	backoff := 500 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		report, err := c.Compute(ctx, user)
+		if err == nil || attempt >= rateLimitRetries || !isRateLimited(err) {
+			return report, err
+		}
+
+		log.Printf("%s: rate limited, retrying in %s (attempt %d/%d)", user, backoff, attempt+1, rateLimitRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRateLimited reports whether err is a Calendar API 403 response for
+// rateLimitExceeded or userRateLimitExceeded.
+func isRateLimited(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != 403 {
+		return false
+	}
+	for _, e := range gerr.Errors {
+		if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
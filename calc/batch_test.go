@@ -0,0 +1,49 @@
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRateLimited(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"not a googleapi.Error", errors.New("boom"), false},
+		{
+			"403 rateLimitExceeded",
+			&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}},
+			true,
+		},
+		{
+			"403 userRateLimitExceeded",
+			&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}},
+			true,
+		},
+		{
+			"403 but unrelated reason",
+			&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}},
+			false,
+		},
+		{
+			"wrong status code",
+			&googleapi.Error{Code: 404, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}},
+			false,
+		},
+		{
+			"wrapped 403 rateLimitExceeded",
+			fmt.Errorf("listing events: %w", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}),
+			true,
+		},
+	} {
+		if got := isRateLimited(tt.err); got != tt.want {
+			t.Errorf("%s: isRateLimited() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package calc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+)
+
+// SlackDigest posts rows as a short text message to a Slack incoming
+// webhook. It's a no-op if rows is empty.
+func SlackDigest(webhookURL string, rows []BatchRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{digestText(rows)})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting to slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailDigest sends rows as a plain-text message to, using the SMTP server
+// at addr (host:port), authenticating with PlainAuth if user is set. It's a
+// no-op if rows is empty.
+func EmailDigest(addr, from, user, password string, to []string, rows []BatchRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "Subject: Vacation balance warnings (%d)\r\n", len(rows))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(digestText(rows))
+
+	var auth smtp.Auth
+	if user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return smtp.SendMail(addr, auth, from, to, []byte(msg.String()))
+}
+
+// digestText renders rows as the plain-text body shared by SlackDigest and
+// EmailDigest.
+func digestText(rows []BatchRow) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Vacation balance warnings (%d):\n", len(rows))
+	for _, r := range rows {
+		fmt.Fprintf(&b, "- %s: balance %.1f (projected EoY %.1f)\n", r.User, r.Balance, r.ProjectedEoYBalance)
+	}
+	return b.String()
+}
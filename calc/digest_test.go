@@ -0,0 +1,81 @@
+package calc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDigestTextRendersEveryRow(t *testing.T) {
+	rows := []BatchRow{
+		{User: "alice@example.org", Balance: 12.5, ProjectedEoYBalance: 8},
+		{User: "bob@example.org", Balance: -2, ProjectedEoYBalance: 5},
+	}
+
+	got := digestText(rows)
+
+	if !strings.Contains(got, "Vacation balance warnings (2):") {
+		t.Errorf("got %q, want a header naming the row count", got)
+	}
+	if !strings.Contains(got, "alice@example.org: balance 12.5 (projected EoY 8.0)") {
+		t.Errorf("got %q, want alice's row rendered", got)
+	}
+	if !strings.Contains(got, "bob@example.org: balance -2.0 (projected EoY 5.0)") {
+		t.Errorf("got %q, want bob's row rendered", got)
+	}
+}
+
+func TestDigestTextEmpty(t *testing.T) {
+	if got := digestText(nil); got != "Vacation balance warnings (0):\n" {
+		t.Errorf("got %q, want just the header for no rows", got)
+	}
+}
+
+func TestSlackDigestSkipsEmptyRows(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	if err := SlackDigest(srv.URL, nil); err != nil {
+		t.Fatalf("SlackDigest(nil) = %v, want nil", err)
+	}
+	if called {
+		t.Error("SlackDigest posted to the webhook for an empty row set")
+	}
+}
+
+func TestSlackDigestPostsText(t *testing.T) {
+	var body struct {
+		Text string `json:"text"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	rows := []BatchRow{{User: "alice@example.org", Balance: 1, ProjectedEoYBalance: 2}}
+	if err := SlackDigest(srv.URL, rows); err != nil {
+		t.Fatalf("SlackDigest() = %v, want nil", err)
+	}
+	if body.Text != digestText(rows) {
+		t.Errorf("got posted text %q, want %q", body.Text, digestText(rows))
+	}
+}
+
+func TestSlackDigestReturnsErrorOnNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := SlackDigest(srv.URL, []BatchRow{{User: "alice@example.org"}}); err == nil {
+		t.Error("expected an error from a non-200 webhook response")
+	}
+}
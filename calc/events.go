@@ -0,0 +1,75 @@
+package calc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// listAllDayEvents returns every all-day event on cal, sorted by start date,
+// with any recurring master event expanded into one event per occurrence.
+func listAllDayEvents(ctx context.Context, srv *calendar.Service, cal string) ([]*calendar.Event, error) {
+	var r []*calendar.Event
+	tok := ""
+	for {
+		events, err := srv.Events.List(cal).ShowDeleted(false).PageToken(tok).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("listing %v: %w", cal, err)
+		}
+
+		for _, i := range events.Items {
+			if i.Start == nil || i.End == nil {
+				continue
+			}
+
+			// If the DateTime is an empty string the Event is an all-day Event and only Date is available.
+			if i.Start.DateTime != "" {
+				continue
+			}
+			if _, _, err := dateSpan(i); err != nil {
+				log.Printf("invalid start/end date %q (%s) %v", i.Start.Date, i.Summary, err)
+				continue
+			}
+
+			r = append(r, i)
+		}
+
+		tok = events.NextPageToken
+		if tok == "" {
+			break
+		}
+	}
+	r = expandRecurringEvents(r, time.Now().AddDate(recurrenceHorizonYears, 0, 0))
+	sort.Sort(byStartDate(r))
+	return r, nil
+}
+
+type byStartDate []*calendar.Event
+
+func (b byStartDate) Len() int           { return len(b) }
+func (b byStartDate) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStartDate) Less(i, j int) bool { return b[i].Start.Date < b[j].Start.Date }
+
+func mustDate(edt *calendar.EventDateTime) time.Time {
+	d, err := time.Parse("2006-01-02", edt.Date)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func dateSpan(ev *calendar.Event) (b, e time.Time, err error) {
+	b, err = time.Parse("2006-01-02", ev.Start.Date)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	e, err = time.Parse("2006-01-02", ev.End.Date)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return b, e, nil
+}
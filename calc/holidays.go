@@ -0,0 +1,331 @@
+package calc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+	"github.com/pkg/errors"
+	"google.golang.org/api/calendar/v3"
+)
+
+const (
+	// HolidayCalendar is the default Google public-holiday calendar, used
+	// unless -holidays names a different one.
+	HolidayCalendar = "en.ch#holiday@group.v.calendar.google.com"
+
+	// KANTON is the default region: if the holiday event description
+	// contains the words 'holiday in', it should also contain this word.
+	KANTON = "Zurich"
+)
+
+// HolidayProvider abstracts where the set of public holidays for a region
+// comes from, so that users outside the original Zürich/Google-calendar
+// setup can plug in their own source.
+type HolidayProvider interface {
+	// Identity is a short, stable string naming the backend and its
+	// configuration (calendar id, region, country/subdivision, ...). It is
+	// stored next to the cached holidays so that switching -holidays or
+	// -region invalidates any cache left over from a different provider.
+	Identity() string
+
+	// Holidays returns a map from date ("2006-01-02") to holiday
+	// description, covering at least the inclusive calendar year range
+	// [minYear, maxYear].
+	Holidays(ctx context.Context, minYear, maxYear int) (map[string]string, error)
+}
+
+// ParseHolidayProvider builds a HolidayProvider from the -holidays flag.
+//
+// Recognised forms:
+//
+//	""                    the Google calendar "en.ch#holiday@group.v.calendar.google.com", filtered by region
+//	google[:calendarID]   a Google calendar, filtered by region
+//	ics:path-or-url       an RFC 5545 ICS file or URL, e.g. ics:/etc/holidaybalance/zh.ics
+//	nager:CC[-SUB]        the Nager.Date REST API, keyed by ISO country code CC and optional subdivision (e.g. nager:CH-ZH)
+func ParseHolidayProvider(spec, region string, srv *calendar.Service) (HolidayProvider, error) {
+	backend, source, _ := strings.Cut(spec, ":")
+
+	switch backend {
+	case "", "google":
+		if source == "" {
+			source = HolidayCalendar
+		}
+		return &googleHolidayProvider{srv: srv, calendarID: source, region: region}, nil
+
+	case "ics":
+		if source == "" {
+			return nil, fmt.Errorf("holidays: ics provider requires a source, e.g. -holidays=ics:/path/to/file.ics")
+		}
+		return &icsHolidayProvider{source: source}, nil
+
+	case "nager":
+		if source == "" {
+			return nil, fmt.Errorf("holidays: nager provider requires a country code, e.g. -holidays=nager:CH-ZH")
+		}
+		country, _, _ := strings.Cut(source, "-")
+		return &nagerHolidayProvider{country: country, subdivision: source}, nil
+
+	default:
+		return nil, fmt.Errorf("holidays: unknown provider %q", backend)
+	}
+}
+
+// googleHolidayProvider reproduces the original behaviour: it reads a
+// Google public-holiday calendar and keeps only the events that either
+// don't mention a specific region ("holiday in ...") or mention ours.
+type googleHolidayProvider struct {
+	srv        *calendar.Service
+	calendarID string
+	region     string
+}
+
+func (p *googleHolidayProvider) Identity() string {
+	return fmt.Sprintf("google:%s:%s", p.calendarID, p.region)
+}
+
+func (p *googleHolidayProvider) Holidays(ctx context.Context, minYear, maxYear int) (map[string]string, error) {
+	events, err := listAllDayEvents(ctx, p.srv, p.calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := map[string]string{}
+	for _, ev := range events {
+		// if the description contains holiday in, it better contain our region too.
+		if strings.Contains(ev.Description, "holiday in") && !strings.Contains(ev.Description, p.region) {
+			continue
+		}
+
+		b, e, err := dateSpan(ev)
+		if err != nil {
+			log.Printf("skipping %q: %v", ev.Summary, err)
+			continue
+		}
+
+		for d := b; d.Before(e); d = d.AddDate(0, 0, 1) {
+			r[d.Format("2006-01-02")] = ev.Summary
+		}
+		log.Printf("Public holiday: %s %d days: %s", ev.Start.Date, e.Sub(b)/(24*time.Hour), ev.Summary)
+	}
+	return r, nil
+}
+
+// icsHolidayProvider parses an RFC 5545 ICS feed, either a local file or an
+// http(s) URL, expanding any RRULE/RDATE/EXDATE recurrence it contains.
+type icsHolidayProvider struct {
+	source string
+}
+
+func (p *icsHolidayProvider) Identity() string {
+	return "ics:" + p.source
+}
+
+func (p *icsHolidayProvider) Holidays(ctx context.Context, minYear, maxYear int) (map[string]string, error) {
+	rc, err := p.open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("opening %s", p.source))
+	}
+	defer rc.Close()
+
+	cal, err := ical.NewDecoder(rc).Decode()
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("parsing %s", p.source))
+	}
+
+	lo := time.Date(minYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	hi := time.Date(maxYear+1, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := map[string]string{}
+	for _, ev := range cal.Events() {
+		summary, _ := ev.Props.Text(ical.PropSummary)
+
+		start, err := ev.DateTimeStart(time.UTC)
+		if err != nil {
+			log.Printf("ics: skipping %q: %v", summary, err)
+			continue
+		}
+		end, err := ev.DateTimeEnd(time.UTC)
+		if err != nil {
+			log.Printf("ics: skipping %q: %v", summary, err)
+			continue
+		}
+		dur := end.Sub(start)
+		if dur <= 0 {
+			dur = 24 * time.Hour
+		}
+
+		ruleSet, err := ev.RecurrenceSet(time.UTC)
+		if err != nil {
+			log.Printf("ics: skipping recurrence of %q: %v", summary, err)
+			continue
+		}
+
+		mark := func(occurrence time.Time) {
+			for d := occurrence; d.Before(occurrence.Add(dur)); d = d.AddDate(0, 0, 1) {
+				if d.Before(lo) || !d.Before(hi) {
+					continue
+				}
+				r[d.Format("2006-01-02")] = summary
+			}
+		}
+
+		if ruleSet == nil {
+			mark(start)
+			continue
+		}
+		for _, occurrence := range ruleSet.Between(lo, hi, true) {
+			mark(occurrence)
+		}
+	}
+	return r, nil
+}
+
+func (p *icsHolidayProvider) open(ctx context.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(p.source, "http://") || strings.HasPrefix(p.source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", p.source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(p.source)
+}
+
+// nagerHolidayProvider queries the Nager.Date public holiday REST API
+// (https://date.nager.at), keyed by ISO 3166-1 country code and an optional
+// ISO 3166-2 subdivision (e.g. "CH-ZH"). Holidays scoped to a subdivision
+// other than ours are skipped.
+type nagerHolidayProvider struct {
+	country     string
+	subdivision string
+}
+
+func (p *nagerHolidayProvider) Identity() string {
+	if p.subdivision != "" && p.subdivision != p.country {
+		return fmt.Sprintf("nager:%s:%s", p.country, p.subdivision)
+	}
+	return "nager:" + p.country
+}
+
+type nagerHoliday struct {
+	Date      string   `json:"date"`
+	LocalName string   `json:"localName"`
+	Counties  []string `json:"counties"`
+}
+
+func (p *nagerHolidayProvider) Holidays(ctx context.Context, minYear, maxYear int) (map[string]string, error) {
+	r := map[string]string{}
+	for y := minYear; y <= maxYear; y++ {
+		url := fmt.Sprintf("https://date.nager.at/api/v3/PublicHolidays/%d/%s", y, p.country)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("fetching %s", url))
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s (unsupported country/subdivision or year?)", url, resp.Status)
+		}
+
+		var days []nagerHoliday
+		err = json.NewDecoder(resp.Body).Decode(&days)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("decoding %s", url))
+		}
+
+		for _, d := range days {
+			if !p.observedHere(d) {
+				continue
+			}
+			r[d.Date] = d.LocalName
+		}
+	}
+	return r, nil
+}
+
+// observedHere reports whether holiday d applies to our subdivision: it does
+// if it has no county restriction (observed nationwide), or if our
+// subdivision is explicitly listed.
+func (p *nagerHolidayProvider) observedHere(d nagerHoliday) bool {
+	if len(d.Counties) == 0 {
+		return true
+	}
+	for _, c := range d.Counties {
+		if c == p.subdivision {
+			return true
+		}
+	}
+	return false
+}
+
+// holidayCache is the on-disk representation of publicholidays.json. It
+// records which provider produced it so that switching regions or backends
+// invalidates the cache automatically rather than silently mixing holidays
+// from two sources.
+type holidayCache struct {
+	Provider  string            `json:"provider"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+	MinYear   int               `json:"minYear"`
+	MaxYear   int               `json:"maxYear"`
+	Holidays  map[string]string `json:"holidays"`
+}
+
+// LoadPublicHolidays reads the holiday cache at path, returning an error if
+// it doesn't exist, is malformed, belongs to a different provider, or
+// doesn't cover the inclusive year range [minYear, maxYear].
+func LoadPublicHolidays(path, identity string, minYear, maxYear int) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c holidayCache
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Provider != identity {
+		return nil, fmt.Errorf("cached holidays are for provider %q, want %q", c.Provider, identity)
+	}
+	if c.MinYear > minYear || c.MaxYear < maxYear {
+		return nil, fmt.Errorf("cached holidays cover %d-%d, want %d-%d", c.MinYear, c.MaxYear, minYear, maxYear)
+	}
+	return c.Holidays, nil
+}
+
+// StorePublicHolidays writes the holiday cache at path, recording the
+// [minYear, maxYear] range h covers so a later LoadPublicHolidays call for a
+// wider range doesn't mistake it for complete. It returns an error instead
+// of exiting the process so that a long-running caller (the -serve HTTP
+// mode) can recover from a transient write failure.
+func StorePublicHolidays(path, identity string, minYear, maxYear int, h map[string]string) error {
+	log.Printf("saving public holidays as %s", path)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	return enc.Encode(holidayCache{Provider: identity, FetchedAt: time.Now(), MinYear: minYear, MaxYear: maxYear, Holidays: h})
+}
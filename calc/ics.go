@@ -0,0 +1,119 @@
+package calc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// xBalanceProp is a non-standard X-property (RFC 5545 section 3.8.8.2)
+// carrying the running balance of the VEVENT it's attached to, in addition
+// to the human-readable line already present in DESCRIPTION.
+const xBalanceProp = "X-HOLIDAYBALANCE-BALANCE"
+
+// ICSFeed renders r's vacation entries ("employee start date" entries are
+// skipped) as an iCalendar VCALENDAR with one VEVENT per vacation, so it can
+// be subscribed to from any calendar client.
+func (r *Report) ICSFeed(now time.Time) ([]byte, error) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, "-//holidaybalance//"+r.User+"//EN")
+	cal.Props.SetText(ical.PropVersion, "2.0")
+
+	for _, e := range r.Entries {
+		if e.Category == "" {
+			continue
+		}
+
+		ev := ical.NewEvent()
+		ev.Props.SetText(ical.PropUID, fmt.Sprintf("%s-%s@holidaybalance", r.CalendarID, e.Start.Format("20060102")))
+		ev.Props.SetDateTime(ical.PropDateTimeStamp, now)
+		ev.Props.SetDate(ical.PropDateTimeStart, e.Start)
+		ev.Props.SetDate(ical.PropDateTimeEnd, e.End)
+		ev.Props.SetText(ical.PropSummary, e.Summary)
+		ev.Props.SetText(ical.PropDescription, balanceLine(e))
+
+		balanceProp := ical.NewProp(xBalanceProp)
+		balanceProp.Value = fmt.Sprintf("%.1f", e.Balance(e.Category))
+		ev.Props.Add(balanceProp)
+
+		cal.Children = append(cal.Children, ev.Component)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FreeBusyICS renders an RFC 5545 VFREEBUSY for [start, end), marking every
+// day that falls within a vacation entry or a public holiday as BUSY.
+func (r *Report) FreeBusyICS(start, end time.Time, now time.Time) ([]byte, error) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, "-//holidaybalance//"+r.User+"//EN")
+	cal.Props.SetText(ical.PropVersion, "2.0")
+
+	fb := ical.NewComponent(ical.CompFreeBusy)
+	fb.Props.SetText(ical.PropUID, fmt.Sprintf("%s-freebusy-%s-%s@holidaybalance", r.CalendarID, start.Format("20060102"), end.Format("20060102")))
+	fb.Props.SetDateTime(ical.PropDateTimeStamp, now)
+	fb.Props.SetDateTime(ical.PropDateTimeStart, start)
+	fb.Props.SetDateTime(ical.PropDateTimeEnd, end)
+
+	for _, period := range r.busyPeriods(start, end) {
+		prop := ical.NewProp(ical.PropFreeBusy)
+		prop.Value = fmt.Sprintf("%s/%s", period[0].UTC().Format("20060102T150405Z"), period[1].UTC().Format("20060102T150405Z"))
+		fb.Props.Add(prop)
+	}
+
+	cal.Children = append(cal.Children, fb)
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// busyPeriods merges leave spans (of any category) and single
+// public-holiday days that overlap [start, end) into a sorted list of
+// non-overlapping [from, to) periods.
+func (r *Report) busyPeriods(start, end time.Time) [][2]time.Time {
+	seen := map[string]bool{}
+	var days []time.Time
+	add := func(d time.Time) {
+		key := d.Format("2006-01-02")
+		if !seen[key] && !d.Before(start) && d.Before(end) {
+			seen[key] = true
+			days = append(days, d)
+		}
+	}
+
+	for _, e := range r.Entries {
+		if e.Category == "" {
+			continue
+		}
+		for d := e.Start; d.Before(e.End); d = d.AddDate(0, 0, 1) {
+			add(d)
+		}
+	}
+	for dd := range r.Holidays {
+		if d, err := time.Parse("2006-01-02", dd); err == nil {
+			add(d)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var periods [][2]time.Time
+	for _, d := range days {
+		if n := len(periods); n > 0 && periods[n-1][1].Equal(d) {
+			periods[n-1][1] = d.AddDate(0, 0, 1)
+			continue
+		}
+		periods = append(periods, [2]time.Time{d, d.AddDate(0, 0, 1)})
+	}
+	return periods
+}
@@ -0,0 +1,62 @@
+package calc
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestBusyPeriodsMergesAdjacentDays(t *testing.T) {
+	r := &Report{
+		Entries: []Entry{
+			{Category: "vacation", Start: date("2024-06-03"), End: date("2024-06-06")}, // Mon-Wed
+		},
+		Holidays: map[string]string{
+			"2024-06-06": "some holiday", // Thu, right after the vacation ends
+		},
+	}
+
+	got := r.busyPeriods(date("2024-01-01"), date("2025-01-01"))
+	if len(got) != 1 {
+		t.Fatalf("got %d periods, want 1 merged period: %v", len(got), got)
+	}
+	if !got[0][0].Equal(date("2024-06-03")) || !got[0][1].Equal(date("2024-06-07")) {
+		t.Errorf("got period %v..%v, want 2024-06-03..2024-06-07", got[0][0], got[0][1])
+	}
+}
+
+func TestBusyPeriodsClipsToRange(t *testing.T) {
+	r := &Report{
+		Entries: []Entry{
+			{Category: "vacation", Start: date("2024-06-01"), End: date("2024-06-10")},
+		},
+	}
+
+	got := r.busyPeriods(date("2024-06-05"), date("2024-06-08"))
+	if len(got) != 1 {
+		t.Fatalf("got %d periods, want 1: %v", len(got), got)
+	}
+	if !got[0][0].Equal(date("2024-06-05")) || !got[0][1].Equal(date("2024-06-08")) {
+		t.Errorf("got period %v..%v, want 2024-06-05..2024-06-08", got[0][0], got[0][1])
+	}
+}
+
+func TestBusyPeriodsSkipsNonVacationEntries(t *testing.T) {
+	r := &Report{
+		Entries: []Entry{
+			{Start: date("2024-01-01"), End: date("2024-01-02")}, // employee start date
+		},
+	}
+
+	got := r.busyPeriods(date("2024-01-01"), date("2025-01-01"))
+	if len(got) != 0 {
+		t.Fatalf("got %d periods, want 0: %v", len(got), got)
+	}
+}
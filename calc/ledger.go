@@ -0,0 +1,148 @@
+package calc
+
+import "time"
+
+// CategoryBalance is one Category's running totals at a point in time.
+type CategoryBalance struct {
+	Name    string
+	Accrued float64
+	Spent   float64
+}
+
+// Balance returns Accrued - Spent.
+func (b CategoryBalance) Balance() float64 { return b.Accrued - b.Spent }
+
+type catTotals struct {
+	accrued float64
+	spent   float64
+}
+
+// Ledger replays a user's calendar events chronologically and tracks every
+// Policy category's running accrued/spent totals, day by day, including
+// tenure-tier accrual bumps and January 1st carry-over caps. It's the
+// engine behind Config.Compute.
+type Ledger struct {
+	policy *Policy
+	cats   map[string]*catTotals
+
+	start    time.Time // employment start date, for tenure tiers
+	fte      float64
+	lastDate time.Time // date accrual has been applied up to; zero until Init
+}
+
+// NewLedger creates an empty Ledger for policy. Init must be called with
+// the employee's start date before any Advance/Spend/At.
+func NewLedger(policy *Policy) *Ledger {
+	l := &Ledger{policy: policy, cats: map[string]*catTotals{}}
+	for _, c := range policy.Categories {
+		l.cats[c.Name] = &catTotals{}
+	}
+	return l
+}
+
+// Init records the employee's employment start date and initial FTE. It
+// does not accrue anything: start is the ledger's baseline.
+func (l *Ledger) Init(start time.Time, fte float64) {
+	l.start = start
+	l.fte = fte
+	l.lastDate = start
+}
+
+// SetFTE changes the FTE used by accrual from now on. Call Advance first
+// if elapsed time up to now should still be credited at the old FTE.
+func (l *Ledger) SetFTE(fte float64) { l.fte = fte }
+
+// Advance accrues every category from its last advance up to date, at the
+// current FTE, applying carry-over caps at every January 1st crossed and
+// breaking at every tenure-tier anniversary so a tier's higher rate only
+// applies from the day it's reached. It is a no-op before Init or if date
+// is not after the last advance.
+func (l *Ledger) Advance(date time.Time) {
+	if l.lastDate.IsZero() || !date.After(l.lastDate) {
+		return
+	}
+
+	cur := l.lastDate
+	for cur.Before(date) {
+		jan1 := time.Date(cur.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		next := date
+		if jan1.Before(next) {
+			next = jan1
+		}
+		for _, c := range l.policy.Categories {
+			for _, t := range c.TenureTiers {
+				if anniv := l.start.AddDate(t.AfterYears, 0, 0); anniv.After(cur) && anniv.Before(next) {
+					next = anniv
+				}
+			}
+		}
+
+		l.accrue(cur, next)
+		if next.Equal(jan1) {
+			l.applyCarryOverCaps()
+		}
+		cur = next
+	}
+	l.lastDate = date
+}
+
+// accrue adds each category's accrual for the span [from, to) to its
+// running total, at the ledger's current FTE and the tenure reached as of
+// from (the caller is expected not to straddle a tenure-tier anniversary).
+func (l *Ledger) accrue(from, to time.Time) {
+	days := float64(to.Sub(from) / (24 * time.Hour))
+	years := tenureYears(l.start, from)
+	for i := range l.policy.Categories {
+		c := &l.policy.Categories[i]
+		l.cats[c.Name].accrued += l.fte * (c.accrualPerYear(years) / 365) * days
+	}
+}
+
+// applyCarryOverCaps clips every capped category's balance down to its cap,
+// forfeiting the excess.
+func (l *Ledger) applyCarryOverCaps() {
+	for i := range l.policy.Categories {
+		c := &l.policy.Categories[i]
+		if c.CarryOverCap <= 0 {
+			continue
+		}
+		t := l.cats[c.Name]
+		if bal := t.accrued - t.spent; bal > c.CarryOverCap {
+			t.accrued -= bal - c.CarryOverCap
+		}
+	}
+}
+
+// Spend advances accrual up to date, then charges days against category's
+// spent total. Spending an unrecognized category is a no-op.
+func (l *Ledger) Spend(category string, date time.Time, days float64) {
+	l.Advance(date)
+	if t, ok := l.cats[category]; ok {
+		t.spent += days
+	}
+}
+
+// At advances accrual up to date and returns a snapshot of every
+// category's balance, in Policy.Categories order.
+func (l *Ledger) At(date time.Time) []CategoryBalance {
+	l.Advance(date)
+	out := make([]CategoryBalance, len(l.policy.Categories))
+	for i, c := range l.policy.Categories {
+		t := l.cats[c.Name]
+		out[i] = CategoryBalance{Name: c.Name, Accrued: t.accrued, Spent: t.spent}
+	}
+	return out
+}
+
+// tenureYears returns the number of full years elapsed between start and
+// at, floored at zero, consistent with start.AddDate(years, 0, 0).
+func tenureYears(start, at time.Time) int {
+	years := at.Year() - start.Year()
+	if at.Month() < start.Month() || (at.Month() == start.Month() && at.Day() < start.Day()) {
+		years--
+	}
+	if years < 0 {
+		years = 0
+	}
+	return years
+}
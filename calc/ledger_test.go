@@ -0,0 +1,101 @@
+package calc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLedgerAccruesAtFTE(t *testing.T) {
+	policy := &Policy{
+		Categories:        []Category{{Name: "vacation", AccrualPerYear: 365}}, // 1/day, easy to check
+		SpanFTEMultiplier: 5,
+	}
+	l := NewLedger(policy)
+	l.Init(date("2024-01-01"), 0.5)
+
+	got := l.At(date("2024-01-11"))[0] // 10 days later
+	if got.Accrued != 5 {
+		t.Errorf("got accrued %v, want 5 (10 days * 0.5 fte * 1/day)", got.Accrued)
+	}
+}
+
+func TestLedgerSpendReducesBalance(t *testing.T) {
+	policy := &Policy{Categories: []Category{{Name: "vacation", AccrualPerYear: 365}}}
+	l := NewLedger(policy)
+	l.Init(date("2024-01-01"), 1)
+
+	l.Spend("vacation", date("2024-01-05"), 2)
+
+	got := l.At(date("2024-01-05"))[0]
+	if got.Accrued != 4 || got.Spent != 2 || got.Balance() != 2 {
+		t.Errorf("got %+v, want accrued 4 spent 2 balance 2", got)
+	}
+}
+
+func TestLedgerCarryOverCapForfeitsExcessAtYearEnd(t *testing.T) {
+	policy := &Policy{Categories: []Category{{Name: "vacation", AccrualPerYear: 365, CarryOverCap: 10}}}
+	l := NewLedger(policy)
+	l.Init(date("2023-01-01"), 1)
+
+	// accrues 365 days by end of year, way over the cap of 10
+	got := l.At(date("2024-01-02"))[0]
+	if got.Accrued != 11 { // capped to 10 on Jan 1st, plus one more day of 2024 accrual
+		t.Errorf("got accrued %v, want 11 (capped to 10, then +1 day)", got.Accrued)
+	}
+}
+
+func TestLedgerTenureTierIncreasesAccrual(t *testing.T) {
+	policy := &Policy{Categories: []Category{{
+		Name: "vacation", AccrualPerYear: 0,
+		TenureTiers: []TenureTier{{AfterYears: 2, ExtraPerYear: 365}}, // 1/day once tenure >= 2y
+	}}}
+	l := NewLedger(policy)
+	l.Init(date("2020-01-01"), 1)
+
+	before := l.At(date("2021-06-01"))[0]
+	if before.Accrued != 0 {
+		t.Errorf("got accrued %v before tenure tier, want 0", before.Accrued)
+	}
+
+	after := l.At(date("2022-01-02"))[0] // tenure reached 2022-01-01
+	if after.Accrued != 1 {
+		t.Errorf("got accrued %v, want 1 (one day accrued once the 2-year tier kicked in)", after.Accrued)
+	}
+}
+
+func TestLedgerIndependentCategories(t *testing.T) {
+	policy := &Policy{Categories: []Category{
+		{Name: "vacation", AccrualPerYear: 365},
+		{Name: "sick", AccrualPerYear: 0},
+	}}
+	l := NewLedger(policy)
+	l.Init(date("2024-01-01"), 1)
+	l.Spend("sick", date("2024-01-03"), 2)
+
+	balances := l.At(date("2024-01-03"))
+	vacation, sick := balances[0], balances[1]
+	if vacation.Spent != 0 {
+		t.Errorf("spending sick leave touched vacation: %+v", vacation)
+	}
+	if sick.Spent != 2 || sick.Accrued != 0 {
+		t.Errorf("got sick %+v, want spent 2 accrued 0", sick)
+	}
+}
+
+func TestTenureYears(t *testing.T) {
+	start := date("2020-03-15")
+	for _, tt := range []struct {
+		at   time.Time
+		want int
+	}{
+		{date("2020-03-15"), 0},
+		{date("2021-03-14"), 0},
+		{date("2021-03-15"), 1},
+		{date("2025-03-14"), 4},
+		{date("2025-03-15"), 5},
+	} {
+		if got := tenureYears(start, tt.at); got != tt.want {
+			t.Errorf("tenureYears(%s, %s) = %d, want %d", start.Format("2006-01-02"), tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package calc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenureTier adds ExtraPerYear to a Category's AccrualPerYear once the
+// employee has been employed for at least AfterYears years. Tiers are
+// cumulative: every tier whose AfterYears has been reached applies.
+type TenureTier struct {
+	AfterYears   int     `json:"afterYears" yaml:"afterYears"`
+	ExtraPerYear float64 `json:"extraPerYear" yaml:"extraPerYear"`
+}
+
+// Category is one named kind of leave (vacation, sick, unpaid, parental,
+// sabbatical, ...) that a Ledger tracks independently.
+type Category struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Regex matches an event's Summary to decide whether it belongs to
+	// this category. Policy.match tries categories in order and returns
+	// the first match, so put more specific patterns first.
+	Regex string `json:"regex" yaml:"regex"`
+	re    *regexp.Regexp
+
+	// AccrualPerYear is how many days of this category accrue per 365
+	// calendar days at 100% FTE. Zero means the category is only ever
+	// spent, never accrued (e.g. unpaid leave).
+	AccrualPerYear float64 `json:"accrualPerYear" yaml:"accrualPerYear"`
+
+	// CarryOverCap, if positive, clips this category's balance to at most
+	// this many days on every January 1st, forfeiting the rest.
+	CarryOverCap float64 `json:"carryOverCap,omitempty" yaml:"carryOverCap,omitempty"`
+
+	// TenureTiers raises AccrualPerYear once the employee has reached
+	// certain years of tenure, e.g. +2 days/year after 5 years.
+	TenureTiers []TenureTier `json:"tenureTiers,omitempty" yaml:"tenureTiers,omitempty"`
+}
+
+// accrualPerYear returns c's accrual rate including any tenure tier
+// reached by tenureYears years of employment.
+func (c *Category) accrualPerYear(tenureYears int) float64 {
+	rate := c.AccrualPerYear
+	for _, t := range c.TenureTiers {
+		if tenureYears >= t.AfterYears {
+			rate += t.ExtraPerYear
+		}
+	}
+	return rate
+}
+
+// Policy configures how a Ledger tracks leave: the categories it
+// recognizes, and the span/FTE heuristic for charging multi-day absences.
+type Policy struct {
+	Categories []Category `json:"categories" yaml:"categories"`
+
+	// SpanFTEMultiplier is the "count as FTE% when span >= N*FTE" rule: an
+	// absence spanning at least SpanFTEMultiplier*FTE calendar days is
+	// charged at FTE% of its working days rather than all of them, so
+	// e.g. a part-timer can book a whole week off as one entry.
+	SpanFTEMultiplier float64 `json:"spanFTEMultiplier" yaml:"spanFTEMultiplier"`
+}
+
+// DefaultPolicy reproduces the tool's original hard-coded behaviour: a
+// single "vacation" category accruing 25 days/year with no carry-over cap
+// or tenure tiers, and the original 5x FTE span heuristic.
+func DefaultPolicy() *Policy {
+	p := &Policy{
+		Categories: []Category{
+			{Name: "vacation", Regex: `(?i)(holiday|vacation)`, AccrualPerYear: 25},
+		},
+		SpanFTEMultiplier: 5,
+	}
+	if err := p.compile(); err != nil {
+		panic(err) // the hard-coded regex above is always valid
+	}
+	return p
+}
+
+// LoadPolicy reads a Policy from a YAML or JSON file, selected by
+// extension (".json" for JSON, anything else for YAML).
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, p)
+	} else {
+		err = yaml.Unmarshal(data, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing policy %s: %w", path, err)
+	}
+
+	if p.SpanFTEMultiplier == 0 {
+		p.SpanFTEMultiplier = 5
+	}
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// compile precompiles every category's Regex.
+func (p *Policy) compile() error {
+	for i := range p.Categories {
+		re, err := regexp.Compile(p.Categories[i].Regex)
+		if err != nil {
+			return fmt.Errorf("category %q: %w", p.Categories[i].Name, err)
+		}
+		p.Categories[i].re = re
+	}
+	return nil
+}
+
+// match returns the first category whose Regex matches summary, or nil if
+// none do.
+func (p *Policy) match(summary string) *Category {
+	for i := range p.Categories {
+		if p.Categories[i].re.MatchString(summary) {
+			return &p.Categories[i]
+		}
+	}
+	return nil
+}
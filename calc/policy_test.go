@@ -0,0 +1,90 @@
+package calc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPolicyMatchesOriginalBehaviour(t *testing.T) {
+	p := DefaultPolicy()
+	if len(p.Categories) != 1 || p.Categories[0].Name != "vacation" || p.Categories[0].AccrualPerYear != 25 {
+		t.Fatalf("got %+v, want a single 25 days/year vacation category", p.Categories)
+	}
+	if p.SpanFTEMultiplier != 5 {
+		t.Errorf("got SpanFTEMultiplier %v, want 5", p.SpanFTEMultiplier)
+	}
+	if p.match("2 days holiday") == nil || p.match("Annual vacation") == nil {
+		t.Error("expected the default policy to match holiday/vacation summaries")
+	}
+	if p.match("sick leave") != nil {
+		t.Error("expected the default policy not to match an unrelated summary")
+	}
+}
+
+func TestLoadPolicyYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlDoc := `
+categories:
+  - name: vacation
+    regex: "(?i)(holiday|vacation)"
+    accrualPerYear: 25
+    carryOverCap: 10
+    tenureTiers:
+      - afterYears: 5
+        extraPerYear: 2
+  - name: sick
+    regex: "(?i)sick"
+    accrualPerYear: 10
+spanFTEMultiplier: 4
+`
+	if err := os.WriteFile(path, []byte(yamlDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Categories) != 2 {
+		t.Fatalf("got %d categories, want 2", len(p.Categories))
+	}
+	if p.SpanFTEMultiplier != 4 {
+		t.Errorf("got SpanFTEMultiplier %v, want 4", p.SpanFTEMultiplier)
+	}
+	if cat := p.match("unpaid sick day"); cat == nil || cat.Name != "sick" {
+		t.Errorf("got %v, want sick category to match", cat)
+	}
+	if p.Categories[0].CarryOverCap != 10 || p.Categories[0].TenureTiers[0].AfterYears != 5 {
+		t.Errorf("got %+v, want carryOverCap 10 and a 5-year tenure tier", p.Categories[0])
+	}
+}
+
+func TestLoadPolicyJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	jsonDoc := `{
+		"categories": [{"name": "unpaid", "regex": "(?i)unpaid", "accrualPerYear": 0}],
+		"spanFTEMultiplier": 5
+	}`
+	if err := os.WriteFile(path, []byte(jsonDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Categories) != 1 || p.Categories[0].Name != "unpaid" {
+		t.Fatalf("got %+v, want a single unpaid category", p.Categories)
+	}
+}
+
+func TestLoadPolicyBadRegexErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("categories:\n  - name: bad\n    regex: \"(\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicy(path); err == nil {
+		t.Error("expected an error from an invalid category regex")
+	}
+}
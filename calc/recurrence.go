@@ -0,0 +1,66 @@
+package calc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+	"google.golang.org/api/calendar/v3"
+)
+
+// recurrenceHorizon bounds how far into the future a recurring event (one
+// with an open-ended RRULE, e.g. "every Friday") is expanded.
+const recurrenceHorizonYears = 2
+
+// expandRecurringEvents replaces each master event that carries an
+// RRULE/RDATE/EXDATE in ev.Recurrence with one event per occurrence up to
+// horizon, so that callers never need to deal with recurrence themselves:
+// a recurring "half day off Friday" or an annually repeating "employee
+// start date" entry is seen as a plain sequence of all-day events, just
+// like ones entered by hand.
+//
+// Events without a Recurrence, and instances the API already expanded
+// (RecurringEventId set), are passed through unchanged.
+func expandRecurringEvents(events []*calendar.Event, horizon time.Time) []*calendar.Event {
+	var out []*calendar.Event
+	for _, ev := range events {
+		if ev.RecurringEventId != "" || len(ev.Recurrence) == 0 {
+			out = append(out, ev)
+			continue
+		}
+
+		occurrences, err := expandRecurrence(ev, horizon)
+		if err != nil {
+			log.Printf("recurrence: skipping %q: %v", ev.Summary, err)
+			out = append(out, ev)
+			continue
+		}
+		out = append(out, occurrences...)
+	}
+	return out
+}
+
+// expandRecurrence expands a single master all-day event into one event per
+// occurrence of its RRULE/RDATE/EXDATE, up to (and including) horizon.
+func expandRecurrence(ev *calendar.Event, horizon time.Time) ([]*calendar.Event, error) {
+	start := mustDate(ev.Start)
+	span := mustDate(ev.End).Sub(start)
+
+	lines := append([]string{"DTSTART:" + strings.ReplaceAll(ev.Start.Date, "-", "")}, ev.Recurrence...)
+	set, err := rrule.StrSliceToRRuleSetInLoc(lines, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parsing RRULE: %v", err)
+	}
+
+	var out []*calendar.Event
+	for _, occ := range set.Between(start, horizon, true) {
+		cp := *ev
+		cp.Start = &calendar.EventDateTime{Date: occ.Format("2006-01-02")}
+		cp.End = &calendar.EventDateTime{Date: occ.Add(span).Format("2006-01-02")}
+		cp.Recurrence = nil
+		out = append(out, &cp)
+	}
+	return out, nil
+}
@@ -0,0 +1,117 @@
+package calc
+
+import (
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func allDay(start, end string, recurrence ...string) *calendar.Event {
+	return &calendar.Event{
+		Summary:    "vacation",
+		Start:      &calendar.EventDateTime{Date: start},
+		End:        &calendar.EventDateTime{Date: end},
+		Recurrence: recurrence,
+	}
+}
+
+func TestExpandRecurringEventsWeekly(t *testing.T) {
+	ev := allDay("2024-01-05", "2024-01-06", "RRULE:FREQ=WEEKLY;BYDAY=FR;COUNT=3")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2024-12-31"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+
+	want := []string{"2024-01-05", "2024-01-12", "2024-01-19"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		if e.Start.Date != want[i] {
+			t.Errorf("occurrence %d: got start %s, want %s", i, e.Start.Date, want[i])
+		}
+		if e.Recurrence != nil {
+			t.Errorf("occurrence %d: expected Recurrence to be cleared, got %v", i, e.Recurrence)
+		}
+	}
+}
+
+func TestExpandRecurringEventsYearly(t *testing.T) {
+	ev := allDay("2020-01-01", "2020-01-02", "RRULE:FREQ=YEARLY;COUNT=3")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2030-01-01"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+
+	want := []string{"2020-01-01", "2021-01-01", "2022-01-01"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		if e.Start.Date != want[i] {
+			t.Errorf("occurrence %d: got start %s, want %s", i, e.Start.Date, want[i])
+		}
+	}
+}
+
+func TestExpandRecurringEventsHonoursExdate(t *testing.T) {
+	ev := allDay("2024-01-05", "2024-01-06",
+		"RRULE:FREQ=WEEKLY;BYDAY=FR;COUNT=3",
+		"EXDATE;VALUE=DATE:20240112")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2024-12-31"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+
+	want := []string{"2024-01-05", "2024-01-19"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		if e.Start.Date != want[i] {
+			t.Errorf("occurrence %d: got start %s, want %s", i, e.Start.Date, want[i])
+		}
+	}
+}
+
+func TestExpandRecurringEventsPreservesSpan(t *testing.T) {
+	// a recurring two-day entry should stay two days long in each occurrence.
+	ev := allDay("2024-01-01", "2024-01-03", "RRULE:FREQ=YEARLY;COUNT=2")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2030-01-01"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+	if len(got) != 2 {
+		t.Fatalf("got %d occurrences, want 2: %v", len(got), got)
+	}
+	if got[1].Start.Date != "2025-01-01" || got[1].End.Date != "2025-01-03" {
+		t.Errorf("second occurrence: got %s..%s, want 2025-01-01..2025-01-03", got[1].Start.Date, got[1].End.Date)
+	}
+}
+
+func TestExpandRecurringEventsPassesThroughNonRecurring(t *testing.T) {
+	ev := allDay("2024-01-01", "2024-01-02")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2030-01-01"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+	if len(got) != 1 || got[0] != ev {
+		t.Fatalf("expected the single non-recurring event to pass through unchanged, got %v", got)
+	}
+}
+
+func TestExpandRecurringEventsPassesThroughAPIExpandedInstances(t *testing.T) {
+	ev := allDay("2024-01-01", "2024-01-02", "RRULE:FREQ=DAILY;COUNT=5")
+	ev.RecurringEventId = "master123"
+	horizon := mustDate(&calendar.EventDateTime{Date: "2030-01-01"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+	if len(got) != 1 || got[0] != ev {
+		t.Fatalf("expected an already-expanded API instance to pass through unchanged, got %v", got)
+	}
+}
+
+func TestExpandRecurrenceInvalidRRuleFallsBackToMaster(t *testing.T) {
+	ev := allDay("2024-01-01", "2024-01-02", "RRULE:NOT-A-VALID-RULE")
+	horizon := mustDate(&calendar.EventDateTime{Date: "2030-01-01"})
+
+	got := expandRecurringEvents([]*calendar.Event{ev}, horizon)
+	if len(got) != 1 || got[0] != ev {
+		t.Fatalf("expected the unparsable master event to pass through unchanged, got %v", got)
+	}
+}
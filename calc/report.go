@@ -0,0 +1,402 @@
+// Package calc computes an employee's vacation balance from their Google
+// calendar, and the public holidays of their region. It is shared by the
+// holidaybalance CLI and its -serve HTTP mode: both call Config.Compute to
+// get a Report, then either print it and patch the calendar (CLI) or render
+// it as JSON/iCalendar (HTTP).
+package calc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	reStartDay = regexp.MustCompile(`(?i)employee\s+start\s+da(y|te)`)
+	reHalfDay  = regexp.MustCompile(`(?i)half\s+day`)
+	rePercent  = regexp.MustCompile(`(\d\d|100)\s?%`)
+)
+
+// Entry is one processed "employee start date" or leave event, in
+// chronological order, carrying every category's running balance as of
+// its end date.
+type Entry struct {
+	Summary string
+	Start   time.Time // inclusive
+	End     time.Time // exclusive, as in the Google Calendar API
+
+	// Category is the Policy category this entry was charged against, or
+	// "" for an "employee start date" entry.
+	Category   string
+	DaysOff    float64 // calendar workdays, ignoring FTE
+	EffDaysOff float64 // days actually charged against Category's balance
+
+	// Balances holds every Policy category's balance as of End, in
+	// Policy.Categories order.
+	Balances []CategoryBalance
+}
+
+// Primary returns the first (by Policy order) category balance, or the
+// zero CategoryBalance if there are none.
+func (e Entry) Primary() CategoryBalance {
+	if len(e.Balances) == 0 {
+		return CategoryBalance{}
+	}
+	return e.Balances[0]
+}
+
+// Balance returns the running balance of category as of End, or zero if
+// category isn't one of this entry's Balances.
+func (e Entry) Balance(category string) float64 {
+	for _, b := range e.Balances {
+		if b.Name == category {
+			return b.Balance()
+		}
+	}
+	return 0
+}
+
+// BalanceLine renders the "vacation from ... to ...: ..." line the CLI
+// prints for each processed entry, and that ApplyUpdates patches into the
+// source event's description.
+func (e Entry) BalanceLine() string { return balanceLine(e) }
+
+// Report is the result of Config.Compute for a single user.
+type Report struct {
+	CalendarID string
+	User       string
+	AsOf       time.Time
+	FTE        float64
+
+	// Categories holds every Policy category's balance as of AsOf, in
+	// Policy.Categories order.
+	Categories []CategoryBalance
+	Entries    []Entry
+	Holidays   map[string]string // date ("2006-01-02") -> name, for the report's date range
+
+	// primaryAccrualPerYear is Categories[0]'s accrual rate as of AsOf
+	// (including any tenure tier reached by then), used by
+	// ProjectedBalance to keep projecting at that same rate.
+	primaryAccrualPerYear float64
+}
+
+// Primary returns the first (by Policy order) category balance, or the
+// zero CategoryBalance if there are none. It's the category the CLI,
+// -serve JSON, ICS and team report render as "the" accrued/spent/balance
+// figures, so a single-category Policy (the default) keeps working exactly
+// as before.
+func (r *Report) Primary() CategoryBalance {
+	if len(r.Categories) == 0 {
+		return CategoryBalance{}
+	}
+	return r.Categories[0]
+}
+
+// Accrued returns Primary().Accrued.
+func (r *Report) Accrued() float64 { return r.Primary().Accrued }
+
+// Spent returns Primary().Spent.
+func (r *Report) Spent() float64 { return r.Primary().Spent }
+
+// Balance returns Primary().Balance().
+func (r *Report) Balance() float64 { return r.Primary().Balance() }
+
+// ProjectedBalance returns Primary()'s balance projected forward to until,
+// assuming no further leave is taken and accrual continues at the FTE in
+// effect as of r.AsOf. If until is not after r.AsOf, it's simply Balance().
+// This is used by team mode to flag balances that will become too high
+// (or too low) by a given date, typically the end of the calendar year.
+func (r *Report) ProjectedBalance(until time.Time) float64 {
+	p := r.Primary()
+	if !until.After(r.AsOf) {
+		return p.Balance()
+	}
+	days := float64(until.Sub(r.AsOf) / (24 * time.Hour))
+	return p.Accrued + r.FTE*(r.primaryAccrualPerYear/365)*days - p.Spent
+}
+
+// Config holds everything Compute needs besides the user's calendar name,
+// so that it can be reused across many calls (CLI: one; server: one per
+// request, sharing the same Srv/Holidays).
+type Config struct {
+	Srv      *calendar.Service
+	Holidays HolidayProvider
+
+	// HolidayCachePath, if non-empty, is where the public-holiday lookup is
+	// cached on disk (see LoadPublicHolidays/StorePublicHolidays).
+	HolidayCachePath string
+
+	// Policy configures the leave categories Compute recognizes and how it
+	// accrues/caps/tenures them. Nil means DefaultPolicy(): a single
+	// "vacation" category accruing 25 days/year, exactly as before Policy
+	// existed.
+	Policy *Policy
+
+	// ApplyUpdates, if true, patches each processed event's description
+	// with its balance line, exactly as the original CLI always did. The
+	// HTTP server leaves it false: it only reads calendars, never writes.
+	ApplyUpdates bool
+
+	// holidaySF coalesces concurrent publicHolidays calls for the same
+	// provider and year range, keyed by Identity()+minYear+maxYear, so two
+	// overlapping Compute calls that want the same range (server: repeat
+	// requests; team mode: worker pool) share one fetch instead of both
+	// missing the cache. Calls for different ranges are never coalesced,
+	// so a caller can't be handed back another caller's narrower range.
+	holidaySF singleflight.Group
+
+	// holidayWriteMu serializes writes to HolidayCachePath across all year
+	// ranges, so two fetches for different ranges (which holidaySF, keyed
+	// per range, does not serialize against each other) can't race writing
+	// the same cache file.
+	holidayWriteMu sync.Mutex
+}
+
+// policy returns c.Policy, or DefaultPolicy() if unset.
+func (c *Config) policy() *Policy {
+	if c.Policy != nil {
+		return c.Policy
+	}
+	return DefaultPolicy()
+}
+
+// Compute reads calName's all-day events and the configured public
+// holidays, and returns the resulting vacation Report. If c.ApplyUpdates is
+// set, it also patches each event's description with its balance line.
+func (c *Config) Compute(ctx context.Context, calName string) (*Report, error) {
+	cal, err := c.Srv.CalendarList.Get(calName).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events from %s: %w", calName, err)
+	}
+	log.Printf("Calendar %q id: %v", calName, cal.Id)
+
+	events, err := listAllDayEvents(ctx, c.Srv, calName)
+	if err != nil {
+		return nil, fmt.Errorf("listing events from %q: %w", calName, err)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events from %q", calName)
+	}
+	endDate := events[0].Start
+	for _, v := range events {
+		if v.End.Date > endDate.Date {
+			endDate = v.End
+		}
+	}
+	log.Printf("Got %d all-day events, from %s to %s", len(events), events[0].Start.Date, endDate.Date)
+
+	holidays, err := c.publicHolidays(ctx, mustDate(events[0].Start).Year(), mustDate(endDate).Year())
+	if err != nil {
+		return nil, fmt.Errorf("fetching public holidays: %w", err)
+	}
+	log.Printf("Got %d public holidays", len(holidays))
+
+	// build map date->workdays since first
+	workdays := map[string]int{}
+	n := 0
+	for d, e := mustDate(events[0].Start), mustDate(endDate).Add(time.Hour); d.Before(e); d = d.AddDate(0, 0, 1) {
+		dd := d.Format("2006-01-02")
+		workdays[dd] = n
+		if holidays[dd] == "" && d.Weekday() != time.Sunday && d.Weekday() != time.Saturday {
+			n++
+		}
+	}
+
+	policy := c.policy()
+	r := &Report{CalendarID: cal.Id, User: calName, Holidays: holidays}
+	ledger := NewLedger(policy)
+
+	var (
+		// startDate is the start of the current employment period, for
+		// example when an employee switched from 60% to 80%.
+		startDate time.Time
+		// lastDate, per category, is the end of the last processed entry
+		// charged against it.
+		lastDate = map[string]*calendar.EventDateTime{}
+	)
+
+	for _, ev := range events {
+		if !startDate.IsZero() {
+			ledger.Advance(mustDate(ev.End))
+		}
+
+		if reStartDay.MatchString(ev.Summary) {
+			fte := 1.0
+			m := rePercent.FindStringSubmatch(ev.Summary)
+			if m == nil {
+				m = rePercent.FindStringSubmatch(ev.Description)
+			}
+			if m != nil {
+				if v, err := strconv.Atoi(m[1]); err == nil && v <= 100 {
+					fte = float64(v) / 100
+				}
+			}
+
+			isFirstDay := startDate.IsZero()
+			if isFirstDay {
+				// This is the very first day of employment.
+				ledger.Init(mustDate(ev.Start), fte)
+			} else {
+				ledger.SetFTE(fte)
+			}
+			startDate = mustDate(ev.Start)
+			r.FTE = fte
+			log.Printf("Start date %v (%2.0f%%)", startDate.Format("2006-01-02"), r.FTE*100)
+
+			// The very first start-date entry snapshots the ledger right where
+			// Init left it, before advancing through its own span, so it always
+			// reads zero/zero, exactly as the original CLI printed it.
+			snapshotAt := mustDate(ev.End)
+			if isFirstDay {
+				snapshotAt = mustDate(ev.Start)
+			}
+			entry := Entry{Summary: ev.Summary, Start: mustDate(ev.Start), End: mustDate(ev.End), Balances: ledger.At(snapshotAt)}
+			r.Entries = append(r.Entries, entry)
+			c.applyUpdate(ctx, cal.Id, ev, entry)
+			continue
+		}
+
+		cat := policy.match(ev.Summary)
+		if cat == nil {
+			continue
+		}
+
+		if startDate.IsZero() {
+			return nil, fmt.Errorf("no employee start date set for %q: create a 1 day entry with summary 'Employee Start Date' and re-run", calName)
+		}
+
+		last := lastDate[cat.Name]
+		if last != nil && last.Date > ev.End.Date {
+			log.Printf("%s from %s to %s already accounted for", cat.Name, ev.Start.Date, ev.End.Date)
+			continue
+		}
+		if last != nil && last.Date > ev.Start.Date {
+			log.Printf("%s from %s to %s partially accounted for up to %s", cat.Name, ev.Start.Date, ev.End.Date, last.Date)
+			ev.Start = last // patch up
+		}
+		lastDate[cat.Name] = ev.End
+
+		daysOff := float64(workdays[ev.End.Date] - workdays[ev.Start.Date])
+		effDaysOff := daysOff
+		// if the calendar period is longer than fte times SpanFTEMultiplier
+		// days, count as fte% days off only, not all
+		calDays := float64(mustDate(ev.End).Sub(mustDate(ev.Start)) / (24 * time.Hour))
+		if calDays >= policy.SpanFTEMultiplier*r.FTE {
+			effDaysOff = r.FTE * daysOff
+		} else if calDays < 1.01 && reHalfDay.MatchString(ev.Summary) {
+			// TODO(lvd) maybe only do this if fte < 60%
+			effDaysOff = .5
+		}
+
+		ledger.Spend(cat.Name, mustDate(ev.End), effDaysOff)
+
+		entry := Entry{
+			Summary: ev.Summary, Start: mustDate(ev.Start), End: mustDate(ev.End),
+			Category: cat.Name, DaysOff: daysOff, EffDaysOff: effDaysOff,
+			Balances: ledger.At(mustDate(ev.End)),
+		}
+		r.Entries = append(r.Entries, entry)
+		c.applyUpdate(ctx, cal.Id, ev, entry)
+	}
+
+	var lastLeaveEnd *calendar.EventDateTime
+	for _, d := range lastDate {
+		if lastLeaveEnd == nil || d.Date > lastLeaveEnd.Date {
+			lastLeaveEnd = d
+		}
+	}
+	if lastLeaveEnd != nil {
+		r.AsOf = time.Date(mustDate(lastLeaveEnd).Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else {
+		r.AsOf = mustDate(endDate)
+	}
+	r.Categories = ledger.At(r.AsOf)
+	if len(policy.Categories) > 0 {
+		r.primaryAccrualPerYear = policy.Categories[0].accrualPerYear(tenureYears(startDate, r.AsOf))
+	}
+
+	return r, nil
+}
+
+// publicHolidays returns the public holidays for [minYear, maxYear],
+// consulting c.HolidayCachePath first when set. Concurrent calls for the
+// same provider and year range are coalesced through c.holidaySF; calls for
+// different ranges each fetch independently, so a caller is never handed
+// back another caller's holidays for the wrong years.
+func (c *Config) publicHolidays(ctx context.Context, minYear, maxYear int) (map[string]string, error) {
+	key := fmt.Sprintf("%s:%d:%d", c.Holidays.Identity(), minYear, maxYear)
+	v, err, _ := c.holidaySF.Do(key, func() (interface{}, error) {
+		if c.HolidayCachePath != "" {
+			if cached, err := LoadPublicHolidays(c.HolidayCachePath, c.Holidays.Identity(), minYear, maxYear); err == nil {
+				return cached, nil
+			}
+		}
+
+		holidays, err := c.Holidays.Holidays(ctx, minYear, maxYear)
+		if err != nil {
+			return nil, err
+		}
+		if c.HolidayCachePath != "" {
+			c.holidayWriteMu.Lock()
+			err := StorePublicHolidays(c.HolidayCachePath, c.Holidays.Identity(), minYear, maxYear, holidays)
+			c.holidayWriteMu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return holidays, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]string), nil
+}
+
+// applyUpdate patches ev's description with its balance line, if
+// c.ApplyUpdates is set. It mirrors the single line the original CLI always
+// appended, replacing any such line left over from a previous run.
+func (c *Config) applyUpdate(ctx context.Context, calID string, ev *calendar.Event, e Entry) {
+	if !c.ApplyUpdates {
+		return
+	}
+	balanceline := balanceLine(e)
+
+	lines := strings.Split(ev.Description, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], "vacation from ") {
+		lines = lines[:len(lines)-1]
+	}
+	lines = append(lines, balanceline)
+	newDescr := strings.Join(lines, "\n")
+
+	if newDescr == ev.Description {
+		log.Printf("No need to modify event %q (%s)", ev.Summary, ev.Start.Date)
+		return
+	}
+
+	if _, err := c.Srv.Events.Patch(calID, ev.Id, &calendar.Event{Description: newDescr}).Context(ctx).Do(); err != nil {
+		log.Printf("Error updating event %q (%s): %v", ev.Summary, ev.Start.Date, err)
+	} else {
+		log.Printf("Updated event %q (%s)", ev.Summary, ev.Start.Date)
+	}
+}
+
+// balanceLine renders the "vacation from ... to ...: ..." line the
+// original CLI appended to an event's description, followed by one
+// "category accrued/spent/balance" clause per category the Report's
+// Policy defines.
+func balanceLine(e Entry) string {
+	var cats []string
+	for _, b := range e.Balances {
+		cats = append(cats, fmt.Sprintf("%s accrued %.1f, spent %.1f balance %.1f", b.Name, b.Accrued, b.Spent, b.Balance()))
+	}
+	return fmt.Sprintf("vacation from %s to %s: %.1f days (effective %.1f); %s",
+		e.Start.Format("2006-01-02"), e.End.Format("2006-01-02"), e.DaysOff, e.EffDaysOff, strings.Join(cats, "; "))
+}
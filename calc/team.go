@@ -0,0 +1,95 @@
+package calc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"io"
+	"strconv"
+)
+
+// WriteCSV writes rows as CSV, one header line followed by one row per
+// user: user, fte, accrued, spent, balance, projectedEoYBalance, error.
+func WriteCSV(w io.Writer, rows []BatchRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"user", "fte", "accrued", "spent", "balance", "projectedEoYBalance", "error"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write([]string{
+			r.User,
+			strconv.FormatFloat(r.FTE, 'f', 2, 64),
+			strconv.FormatFloat(r.Accrued, 'f', 1, 64),
+			strconv.FormatFloat(r.Spent, 'f', 1, 64),
+			strconv.FormatFloat(r.Balance, 'f', 1, 64),
+			strconv.FormatFloat(r.ProjectedEoYBalance, 'f', 1, 64),
+			errString(r.Err),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonRow is BatchRow's wire representation: camelCase fields and Err
+// rendered as a plain string so a failed row doesn't break decoding.
+type jsonRow struct {
+	User                string  `json:"user"`
+	FTE                 float64 `json:"fte"`
+	Accrued             float64 `json:"accrued"`
+	Spent               float64 `json:"spent"`
+	Balance             float64 `json:"balance"`
+	ProjectedEoYBalance float64 `json:"projectedEoYBalance"`
+	Error               string  `json:"error,omitempty"`
+}
+
+// WriteJSON writes rows as a JSON array of {user, fte, accrued, spent,
+// balance, projectedEoYBalance, error}.
+func WriteJSON(w io.Writer, rows []BatchRow) error {
+	out := make([]jsonRow, len(rows))
+	for i, r := range rows {
+		out[i] = jsonRow{r.User, r.FTE, r.Accrued, r.Spent, r.Balance, r.ProjectedEoYBalance, errString(r.Err)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+var teamHTMLTemplate = template.Must(template.New("team").Parse(`<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>user</th><th>fte</th><th>accrued</th><th>spent</th><th>balance</th><th>projected EoY balance</th></tr>
+{{range .}}<tr>
+<td>{{.User}}</td>
+{{if .Err}}<td colspan="5">error: {{.Err}}</td>
+{{else}}<td>{{printf "%.2f" .FTE}}</td><td>{{printf "%.1f" .Accrued}}</td><td>{{printf "%.1f" .Spent}}</td><td>{{printf "%.1f" .Balance}}</td><td>{{printf "%.1f" .ProjectedEoYBalance}}</td>
+{{end}}</tr>
+{{end}}</table>
+`))
+
+// WriteHTML renders rows as an HTML table, one row per user, error rows
+// collapsed into a single cell.
+func WriteHTML(w io.Writer, rows []BatchRow) error {
+	return teamHTMLTemplate.Execute(w, rows)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Warnings returns the rows (skipping any that errored) whose Balance
+// falls below warnBelow or above warnAbove.
+func Warnings(rows []BatchRow, warnBelow, warnAbove float64) []BatchRow {
+	var out []BatchRow
+	for _, r := range rows {
+		if r.Err != nil {
+			continue
+		}
+		if r.Balance < warnBelow || r.Balance > warnAbove {
+			out = append(out, r)
+		}
+	}
+	return out
+}
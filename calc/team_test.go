@@ -0,0 +1,45 @@
+package calc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSVIncludesErrorRows(t *testing.T) {
+	rows := []BatchRow{
+		{User: "alice@example.org", FTE: 1, Accrued: 25, Spent: 10, Balance: 15, ProjectedEoYBalance: 20},
+		{User: "bob@example.org", Err: errors.New("no employee start date")},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "alice@example.org,1.00,25.0,10.0,15.0,20.0,") {
+		t.Errorf("missing alice row: %q", out)
+	}
+	if !strings.Contains(out, "bob@example.org,0.00,0.0,0.0,0.0,0.0,no employee start date") {
+		t.Errorf("missing bob error row: %q", out)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	rows := []BatchRow{
+		{User: "low@example.org", Balance: -2},
+		{User: "ok@example.org", Balance: 10},
+		{User: "high@example.org", Balance: 40},
+		{User: "broken@example.org", Balance: -100, Err: errors.New("boom")},
+	}
+
+	got := Warnings(rows, 0, 30)
+	if len(got) != 2 {
+		t.Fatalf("got %d warnings, want 2: %v", len(got), got)
+	}
+	if got[0].User != "low@example.org" || got[1].User != "high@example.org" {
+		t.Errorf("got %v, want low then high", got)
+	}
+}
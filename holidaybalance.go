@@ -5,25 +5,112 @@
 //      go install github.com/lvdlvd/holidaybalance
 //      # obtain a client_secret.json as per https://developers.google.com/google-apps/calendar/quickstart/go
 //
-//      holidaybalance [-n] user@yourdomain.ai
+//      holidaybalance [-n] [-region Zurich] [-holidays spec] user@yourdomain.ai
 //
 //  The -n flag supresses the updating of calendar entries.
 //
 //  The program iterates over the listed calendar for whole-day entries with
 //  summary (title) containing the words "employee start date" and "{vacation|holiday} [half day]".
+//  A recurring entry (weekly, yearly, ...) is expanded into one such entry per
+//  occurrence, up to two years out, so a repeating "half day off Friday" or an
+//  annually recurring FTE change both work like a series of one-off entries.
 //
-//  The program will query the public holiday calendar for Switzerland/Zürich. It caches a local copy.
+//  The program will query a public holiday calendar and caches a local copy in
+//  publicholidays.json. By default that's the Google calendar for Switzerland,
+//  filtered to the region named by -region (default "Zurich"). The -holidays flag
+//  selects a different source:
+//
+//      -holidays google:<calendarID>   a different Google holiday calendar, still filtered by -region
+//      -holidays ics:path-or-url       an RFC 5545 ICS feed (file path or http(s) URL)
+//      -holidays nager:CC[-SUB]        the Nager.Date REST API, e.g. nager:CH-ZH
+//
+//  The cache records which provider produced it, so switching -region or -holidays
+//  invalidates it automatically.
 //
 //  Then it computes for each day the accrued and used holidays for that employee.
-//  Vacation days accrue at a rate of 25 days per 365 days, that is one for every 14.6 calendar days.
+//  By default vacation days accrue at a rate of 25 days per 365 days, that is one for every
+//  14.6 calendar days; see ACCRUAL POLICY below to configure this.
 //  2020-02-29 will be declared an extra public holiday. We'll see about 2024.
 //
-//  The descriptions of all "vacation" entries in the entire history will then be updated with
-//  a final line Vacation from ...to... accrued/used/balance per the end date.
+//  The descriptions of all processed entries in the entire history will then be updated with
+//  a final line listing accrued/used/balance, per category, as of the entry's end date.
 //
 //  The program enforces no policies about allowed ranges of the balance, it is merely a tool to
 //  keep an eye on them.
 //
+// ACCRUAL POLICY
+//
+//  holidaybalance -policy policy.yaml user@yourdomain.ai
+//
+//  By default the program recognizes a single leave category, "vacation",
+//  matched against any event summary containing "holiday" or "vacation",
+//  accruing 25 days/year. The -policy flag points at a YAML (or, with a
+//  .json extension, JSON) file overriding this with one or more categories:
+//
+//      categories:
+//        - name: vacation
+//          regex: '(?i)(holiday|vacation)'
+//          accrualPerYear: 25
+//          carryOverCap: 10          # forfeit balance above 10 days every Jan 1st
+//          tenureTiers:
+//            - afterYears: 5
+//              extraPerYear: 2       # +2 days/year once tenure reaches 5 years
+//        - name: sick
+//          regex: '(?i)sick'
+//          accrualPerYear: 10
+//        - name: unpaid
+//          regex: '(?i)unpaid'
+//          accrualPerYear: 0         # never accrues, only ever spent
+//      spanFTEMultiplier: 5          # the "count as FTE% when span >= Nx FTE" rule
+//
+//  An event's summary is matched against each category's regex in order;
+//  the first match wins. Categories are tracked independently: a "sick"
+//  entry doesn't touch the "vacation" balance, and so on.
+//
+// SERVER MODE
+//
+//  holidaybalance -serve :8080 [-region Zurich] [-holidays spec]
+//
+//  Instead of a single user and a one-shot update, -serve starts a read-only
+//  HTTP server (it never patches calendar entries) exposing:
+//
+//      GET /balance/user@yourdomain.ai        JSON {accrued, spent, balance, fte, asOf}
+//      GET /balance/user@yourdomain.ai.ics     an iCalendar feed, one VEVENT per vacation
+//      GET /freebusy/user@yourdomain.ai?start=2026-01-01&end=2026-12-31
+//                                              an RFC 5545 VFREEBUSY for that range
+//
+//  Per-user reports are cached in memory for -cache-ttl (default 15m) so that
+//  repeated requests don't hit the Calendar API and the holiday provider on
+//  every poll.
+//
+//  This exposes vacation balances and free/busy availability, which is
+//  personal data, to anyone who can reach addr. Run it behind an
+//  authenticating reverse proxy, or set -auth-token and require every
+//  caller to send "Authorization: Bearer <token>".
+//
+// TEAM MODE
+//
+//  holidaybalance [-format csv|json|html] [-out report.csv] user1@yourdomain.ai user2@yourdomain.ai ...
+//  holidaybalance -team team.txt [-format csv|json|html] [-out report.csv]
+//
+//  Given more than one calendar, either as extra positional arguments or
+//  listed one per line in the file named by -team ('#' starts a comment),
+//  the program computes every user's balance (honouring -n, as usual) and
+//  writes a consolidated report of {user, fte, accrued, spent, balance,
+//  projectedEoYBalance} rows in the format named by -format (default csv)
+//  to -out (default stdout). projectedEoYBalance projects the balance
+//  forward to December 31 of the current year at the user's current FTE,
+//  assuming no further vacation is booked.
+//
+//  Calendars are fetched concurrently, -concurrency (default 4) at a time,
+//  retrying with exponential backoff on a 403 rateLimitExceeded response
+//  from the Calendar API.
+//
+//  If -warn-below or -warn-above is set, any user whose balance falls
+//  outside that range is also reported via -slack-webhook (a Slack
+//  incoming webhook URL) and/or -smtp-addr (an SMTP server, with -smtp-from,
+//  -smtp-to, and optionally -smtp-user/-smtp-password).
+//
 // PART TIME EMPLOYEES
 //
 //  The employee start date entry can have an optional description containing 'xxx %' to set a percentage
@@ -62,406 +149,104 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"math"
 	"os"
-	"os/user"
 	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
-	"strings"
 	"time"
 
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-
-	"github.com/pkg/errors"
-	"google.golang.org/api/calendar/v3"
+	"github.com/lvdlvd/holidaybalance/calc"
 )
 
 var (
-	reStartDay = regexp.MustCompile(`(?i)employee\s+start\s+da(y|te)`)
-	reVacation = regexp.MustCompile(`(?i)(holiday|vacation)`)
-	reHalfDay  = regexp.MustCompile(`(?i)half\s+day`)
-	rePercent  = regexp.MustCompile(`(\d\d|100)\s?%`)
-)
-
-const (
-	// for every calendary year get 25 days of holiday. TODO make feb 29 an extra public holiday.
-	HolidaysPerCalendarDay = 25. / 365.
-
-	// source of public holidays
-	holidayCalendar = "en.ch#holiday@group.v.calendar.google.com"
-
-	// if the holiday event description contains the words 'holiday in', it should also contain this word.
-	KANTON = "Zurich"
+	noUpdate = flag.Bool("n", false, "don't update the calendar entries with new descriptions")
+	region   = flag.String("region", calc.KANTON, "region name used to filter the Google holiday calendar (ignored by other -holidays backends)")
+	holidays = flag.String("holidays", "", `source of public holidays: "" or "google[:calendarID]" for a Google calendar,
+	"ics:path-or-url" for an RFC 5545 feed, or "nager:CC[-SUB]" for the Nager.Date API (e.g. nager:CH-ZH)`)
+	serve     = flag.String("serve", "", "if set, run a read-only HTTP server on this address (e.g. :8080) instead of processing a single user")
+	cacheTTL  = flag.Duration("cache-ttl", 15*time.Minute, "how long -serve caches a user's report in memory before recomputing it")
+	authToken = flag.String("auth-token", "", "in -serve mode, require this bearer token (Authorization: Bearer <token>) on every request; if unset, -serve must run behind an authenticating proxy")
+	policy    = flag.String("policy", "", "YAML or JSON accrual policy file (see ACCRUAL POLICY); default is a single 25 days/year \"vacation\" category")
+
+	team        = flag.String("team", "", "file of calendar names, one per line ('#' starts a comment); triggers team mode, together with any extra positional arguments")
+	format      = flag.String("format", "csv", "team mode report format: csv, json, or html")
+	out         = flag.String("out", "-", `team mode report output path, or "-" for stdout`)
+	concurrency = flag.Int("concurrency", 4, "number of calendars to process concurrently in team mode")
+
+	warnBelow    = flag.Float64("warn-below", math.Inf(-1), "in team mode, include a user in the warning digest if their balance falls below this")
+	warnAbove    = flag.Float64("warn-above", math.Inf(1), "in team mode, include a user in the warning digest if their balance rises above this")
+	slackWebhook = flag.String("slack-webhook", "", "in team mode, post the warning digest to this Slack incoming webhook URL")
+	smtpAddr     = flag.String("smtp-addr", "", "in team mode, send the warning digest by email via this SMTP server (host:port)")
+	smtpFrom     = flag.String("smtp-from", "", "From: address for -smtp-addr")
+	smtpTo       = flag.String("smtp-to", "", "comma-separated To: addresses for -smtp-addr")
+	smtpUser     = flag.String("smtp-user", "", "SMTP auth username, if required by -smtp-addr")
+	smtpPassword = flag.String("smtp-password", "", "SMTP auth password, if required by -smtp-addr")
 )
 
-var noUpdate = flag.Bool("n", false, "don't update the calendar entries with new descriptions")
-
 func main() {
-
 	flag.Parse()
 
-	if len(flag.Args()) != 1 {
-		log.Fatalf("Usage: %s user@example.org", os.Args[0])
-	}
-	calName := flag.Arg(0)
-
 	srv := getClient()
 
-	cal, err := srv.CalendarList.Get(calName).Do()
+	holidayProvider, err := calc.ParseHolidayProvider(*holidays, *region, srv)
 	if err != nil {
-		log.Fatalln(errors.Wrap(err, fmt.Sprintf("Failed to get events from %s", calName)))
+		log.Fatal(err)
 	}
-	log.Printf("Calendar %q id: %v", calName, cal.Id)
-
-	hfile := filepath.Join(filepath.Dir(os.Args[0]), "publicholidays.json")
-	holidays, err := loadPublicHolidays(hfile)
-	if err != nil {
-		log.Printf("Loading cached public holidays: %v", err)
-		log.Printf("Updating cached public holidays...")
-		holidays = getPublicHolidays(srv)
-		storePublicHolidays(hfile, holidays)
-	}
-	log.Printf("Got %d public holidays", len(holidays))
-
-	events := listAllDayEvents(srv, calName)
-	if len(events) == 0 {
-		log.Fatalf("No events from %q", calName)
-	}
-	endDate := events[0].Start
-	for _, v := range events {
-		if v.End.Date > endDate.Date {
-			endDate = v.End
-		}
-	}
-	log.Printf("Got %d all-day events, from %s to %s", len(events), events[0].Start.Date, endDate.Date)
-
-	// build map date->workdays since first
-	workdays := map[string]int{}
-	n := 0
-	for d, e := mustDate(events[0].Start), mustDate(endDate).Add(time.Hour); d.Before(e); d = d.AddDate(0, 0, 1) {
-		dd := d.Format("2006-01-02")
-		workdays[dd] = n
-		if holidays[dd] == "" && d.Weekday() != time.Sunday && d.Weekday() != time.Saturday {
-			n++
-		}
-	}
-
-	var (
-		// startDate is the start of the current employment period,
-		// for example when an employee switched from 60% to 80%.
-		startDate time.Time
-		// lastDate is the end of the last processed event past the startDate.
-		// It's used for computing accrued.
-		lastDate *calendar.EventDateTime
-		// lastVacationDate is the end of the last processed vacation event.
-		lastVacationDate *calendar.EventDateTime
-		// fte is the employment percent, 1 = 100%
-		fte float64
-		// accrued represents how much vacation is available.
-		accrued float64
-		// spent represent how much vacation has been used.
-		spent float64
-	)
-
-	for _, ev := range events {
-		if lastDate != nil {
-			accrued += fte * HolidaysPerCalendarDay * float64(mustDate(ev.End).Sub(mustDate(lastDate))/(24*time.Hour))
-			lastDate = ev.End
-		}
-
-		if reStartDay.MatchString(ev.Summary) {
-			if startDate.IsZero() {
-				// This is the very first day of employment.
-				lastDate = ev.Start
-			}
-
-			startDate = mustDate(ev.Start)
-			m := rePercent.FindStringSubmatch(ev.Summary)
-			if m == nil {
-				m = rePercent.FindStringSubmatch(ev.Description)
-			}
-			if m != nil {
-				v, err := strconv.Atoi(m[1])
-				if err == nil && v <= 100 {
-					fte = float64(v) / 100
-				}
-			} else {
-				fte = 1.0
-			}
-			log.Printf("Start date %v (%2.0f%%)", startDate.Format("2006-01-02"), fte*100)
-			updateEvent(srv, cal.Id, ev, 0, 0, accrued, spent)
-			continue
-		}
-
-		if reVacation.MatchString(ev.Summary) {
-			if startDate.IsZero() {
-				log.Fatal("no employee start date set. create a 1 day entry with summary 'Employee Start Date' and re-run this program.")
-			}
-
-			if lastDate.Date > ev.End.Date {
-				log.Printf("vacation from %s to %s already accounted for", ev.Start.Date, ev.End.Date)
-				continue
-			}
-
-			if lastVacationDate != nil && lastVacationDate.Date > ev.Start.Date {
-				log.Printf("vacation from %s to %s partially accounted for up to %s", ev.Start.Date, ev.End.Date, lastVacationDate.Date)
-				ev.Start = lastVacationDate // patch up
-			}
-			lastVacationDate = ev.End
 
-			daysOff := float64(workdays[ev.End.Date] - workdays[ev.Start.Date])
-			effDaysOff := daysOff
-			// if the calendar period is longer than fte times a week, count as fte% days off only, not all
-			calDays := float64(mustDate(ev.End).Sub(mustDate(ev.Start)) / (24 * time.Hour))
-			if calDays >= 5*fte {
-				effDaysOff = fte * daysOff
-			} else if calDays < 1.01 && reHalfDay.MatchString(ev.Summary) {
-				// TODO(lvd) maybe only do this if fte < 60%
-				effDaysOff = .5
-			}
-
-			spent += effDaysOff
-
-			updateEvent(srv, cal.Id, ev, daysOff, effDaysOff, accrued, spent)
+	var leavePolicy *calc.Policy
+	if *policy != "" {
+		leavePolicy, err = calc.LoadPolicy(*policy)
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
 
-	if lastVacationDate != nil {
-		now := time.Now()
-		y := mustDate(lastVacationDate).Year()
-		eoy := time.Date(y+1, 1, 1, 0, 0, 0, 0, now.Location())
-		accruedEoy := accrued + fte*HolidaysPerCalendarDay*float64(eoy.Sub(mustDate(lastVacationDate))/(24*time.Hour))
-		fmt.Printf("vacation at %s: accrued %.1f, balance %.1f\n", eoy, accruedEoy, accruedEoy-spent)
+	cfg := &calc.Config{
+		Srv:              srv,
+		Holidays:         holidayProvider,
+		HolidayCachePath: filepath.Join(filepath.Dir(os.Args[0]), "publicholidays.json"),
+		Policy:           leavePolicy,
 	}
-}
-
-func updateEvent(srv *calendar.Service, calId string, ev *calendar.Event, daysOff, effDaysOff, accrued, spent float64) {
-	balanceline := fmt.Sprintf("vacation from %s to %s: %.1f days (effective %.1f), accrued %.1f, spent %.1f balance %.1f",
-		ev.Start.Date, ev.End.Date, daysOff, effDaysOff, accrued, spent, accrued-spent)
-	fmt.Println(balanceline)
 
-	if *noUpdate {
+	if *serve != "" {
+		runServer(cfg, *serve, *cacheTTL, *authToken)
 		return
 	}
 
-	lines := strings.Split(ev.Description, "\n")
-	if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], "vacation from ") {
-		lines = lines[:len(lines)-1]
-	}
-	lines = append(lines, balanceline)
-	newDescr := strings.Join(lines, "\n")
-
-	if newDescr != ev.Description {
-		if _, err := srv.Events.Patch(calId, ev.Id, &calendar.Event{Description: newDescr}).Do(); err != nil {
-			log.Printf("Error updating event %q (%s): %v", ev.Summary, ev.Start.Date, err)
-		} else {
-			log.Printf("Updated event %q (%s)", ev.Summary, ev.Start.Date)
-		}
-	} else {
-		log.Printf("No need to modify event %q (%s)", ev.Summary, ev.Start.Date)
-	}
-}
-
-func listAllDayEvents(srv *calendar.Service, cal string) []*calendar.Event {
-	var r []*calendar.Event
-	tok := ""
-	for {
-		events, err := srv.Events.List(cal).ShowDeleted(false).PageToken(tok).Do()
+	users := flag.Args()
+	if *team != "" {
+		teamUsers, err := readTeamFile(*team)
 		if err != nil {
-			log.Fatalf("Listing %v: %v", cal, err)
-		}
-
-		for _, i := range events.Items {
-			if i.Start == nil || i.End == nil {
-				continue
-			}
-
-			// If the DateTime is an empty string the Event is an all-day Event and only Date is available.
-			if i.Start.DateTime != "" {
-				continue
-			}
-			if _, _, err := dateSpan(i); err != nil {
-				log.Printf("invalid start/end date %q (%s) %v", i.Start.Date, i.Summary, err)
-				continue
-			}
-
-			r = append(r, i)
-		}
-
-		tok = events.NextPageToken
-		if tok == "" {
-			break
+			log.Fatal(err)
 		}
+		users = append(users, teamUsers...)
 	}
-	sort.Sort(byStartDate(r))
-	return r
-}
-
-type byStartDate []*calendar.Event
-
-func (b byStartDate) Len() int           { return len(b) }
-func (b byStartDate) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byStartDate) Less(i, j int) bool { return b[i].Start.Date < b[j].Start.Date }
+	cfg.ApplyUpdates = !*noUpdate
 
-func mustDate(edt *calendar.EventDateTime) time.Time {
-	d, err := time.Parse("2006-01-02", edt.Date)
-	if err != nil {
-		panic(err)
-	}
-	return d
-}
-
-func dateSpan(ev *calendar.Event) (b, e time.Time, err error) {
-	b, err = time.Parse("2006-01-02", ev.Start.Date)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
-	}
-	e, err = time.Parse("2006-01-02", ev.End.Date)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
-	}
-	return b, e, nil
-}
-
-func loadPublicHolidays(path string) (map[string]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	r := map[string]string{}
-	err = json.NewDecoder(f).Decode(&r)
-	return r, err
-}
-
-func storePublicHolidays(path string, h map[string]string) {
-	log.Printf("saving public holidays as %s", path)
-	f, err := os.Create(path)
-	if err != nil {
-		log.Fatalf("Unable to write %s: %v", path, err)
-	}
-	defer f.Close()
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "\t")
-	enc.Encode(h)
-}
-
-// Return sorted list of days with public holidays in Zürich.
-func getPublicHolidays(srv *calendar.Service) map[string]string {
-	r := map[string]string{}
-
-	for _, ev := range listAllDayEvents(srv, holidayCalendar) {
-
-		// if the description contains holiday in, it better contain Zurich too.
-		if strings.Contains(ev.Description, "holiday in") && !strings.Contains(ev.Description, KANTON) {
-			continue
+	if *team != "" || len(users) > 1 {
+		opts := teamOptions{
+			format: *format, out: *out, concurrency: *concurrency,
+			warnBelow: *warnBelow, warnAbove: *warnAbove, slackWebhook: *slackWebhook,
+			smtpAddr: *smtpAddr, smtpFrom: *smtpFrom, smtpTo: *smtpTo, smtpUser: *smtpUser, smtpPassword: *smtpPassword,
 		}
-
-		b, e, err := dateSpan(ev)
-		if err != nil {
-			log.Printf("skipping %q: %v", ev.Summary, err)
-			continue
-		}
-
-		for d := b; d.Before(e); d = d.AddDate(0, 0, 1) {
-			r[d.Format("2006-01-02")] = ev.Summary
+		if err := runTeam(cfg, users, opts); err != nil {
+			log.Fatal(err)
 		}
-		log.Printf("Public holiday: %s %d days: %s", ev.Start.Date, e.Sub(b)/(24*time.Hour), ev.Summary)
-	}
-
-	return r
-}
-
-func homeDir() string {
-	usr, err := user.Current()
-	if err != nil {
-		log.Fatalln(err)
-	}
-	return usr.HomeDir
-}
-
-// getTokenFromWeb uses Config to request a Token.
-// It returns the retrieved Token.
-func tokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	log.Printf("Go to the following link in your browser then type the authorization code: \n%v\n", authURL)
-
-	var code string
-	if _, err := fmt.Scan(&code); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
-	}
-
-	tok, err := config.Exchange(oauth2.NoContext, code)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
-	}
-	return tok
-}
-
-// tokenFromFile retrieves a Token from a given file path.
-// It returns the retrieved Token and any read error encountered.
-func loadToken(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	t := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(t)
-	return t, err
-}
-
-// saveToken uses a file path to create a file and store the
-// token in it.
-func saveToken(file string, token *oauth2.Token) {
-	log.Printf("Saving credential file to: %s\n", file)
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
-}
-
-// getClient uses a Context and Config to retrieve a Token
-// then generate a Client. It returns the generated Client.
-func getClient() *calendar.Service {
-
-	home := homeDir()
-	basename := filepath.Base(os.Args[0])
-
-	cs, err := ioutil.ReadFile(filepath.Join(filepath.Dir(os.Args[0]), "client_secret.json"))
-	if err != nil {
-		cs, err = ioutil.ReadFile(filepath.Join(home, ".credentials", "client_secret.json"))
-	}
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return
 	}
 
-	config, err := google.ConfigFromJSON(cs, calendar.CalendarScope)
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+	if len(users) != 1 {
+		log.Fatalf("Usage: %s user@example.org [user2@example.org ...] | -team file.txt", os.Args[0])
 	}
 
-	tokenCacheDir := filepath.Join(home, ".credentials")
-	cacheFile := filepath.Join(tokenCacheDir, basename+".json")
-	tok, err := loadToken(cacheFile)
+	report, err := cfg.Compute(context.Background(), users[0])
 	if err != nil {
-		os.MkdirAll(tokenCacheDir, 0700)
-		tok = tokenFromWeb(config)
-		saveToken(cacheFile, tok)
+		log.Fatal(err)
 	}
-
-	srv, err := calendar.New(config.Client(context.Background(), tok))
-	if err != nil {
-		log.Fatalf("Unable to construct calendar Client %v", err)
+	for _, e := range report.Entries {
+		fmt.Println(e.BalanceLine())
 	}
-	return srv
+	fmt.Printf("vacation at %s: accrued %.1f, balance %.1f\n", report.AsOf.Format("2006-01-02"), report.Accrued(), report.Balance())
 }
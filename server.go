@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/lvdlvd/holidaybalance/calc"
+)
+
+// runServer starts the -serve HTTP mode: a read-only server exposing
+// balances and iCalendar/free-busy feeds for any user reachable with cfg's
+// credentials. cfg.ApplyUpdates is ignored; it is always treated as false.
+//
+// It serves vacation balances and free/busy ranges, which are personal
+// data, so it must run behind an authenticating reverse proxy, a private
+// network boundary, or authToken: if authToken is non-empty, every request
+// must carry "Authorization: Bearer <authToken>". Running with an empty
+// authToken outside such a boundary exposes every reachable calendar's
+// balance to anyone who can reach addr.
+func runServer(cfg *calc.Config, addr string, cacheTTL time.Duration, authToken string) {
+	cfg.ApplyUpdates = false
+	srv := &server{cfg: cfg, ttl: cacheTTL, authToken: authToken}
+
+	if authToken == "" {
+		log.Printf("WARNING: -serve is running without -auth-token; anyone who can reach %s can read every reachable calendar's vacation balance and free/busy schedule. Set -auth-token or put this behind an authenticating proxy.", addr)
+	}
+
+	router := httprouter.New()
+	router.GET("/balance/:user", srv.authenticate(srv.handleBalance))
+	router.GET("/freebusy/:user", srv.authenticate(srv.handleFreeBusy))
+
+	log.Printf("Listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, router))
+}
+
+// server holds the in-memory report cache shared by the HTTP handlers.
+type server struct {
+	cfg       *calc.Config
+	ttl       time.Duration
+	authToken string
+
+	mu    sync.Mutex
+	cache map[string]cachedReport
+}
+
+// authenticate wraps next, rejecting the request unless it carries
+// "Authorization: Bearer <s.authToken>". It's a no-op if s.authToken is
+// empty.
+func (s *server) authenticate(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.authToken != "" {
+			got := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.authToken)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, req, ps)
+	}
+}
+
+type cachedReport struct {
+	report    *calc.Report
+	computeAt time.Time
+}
+
+// reportFor returns the cached Report for user if it's younger than s.ttl,
+// else recomputes and caches it.
+func (s *server) reportFor(ctx context.Context, user string) (*calc.Report, error) {
+	s.mu.Lock()
+	if c, ok := s.cache[user]; ok && time.Since(c.computeAt) < s.ttl {
+		s.mu.Unlock()
+		return c.report, nil
+	}
+	s.mu.Unlock()
+
+	report, err := s.cfg.Compute(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = map[string]cachedReport{}
+	}
+	s.cache[user] = cachedReport{report: report, computeAt: time.Now()}
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+// userAndFormat splits a "user@example.org.ics"-style path parameter into
+// the plain user and the requested format ("json" or "ics").
+func userAndFormat(raw string) (user, format string) {
+	if u, ok := strings.CutSuffix(raw, ".ics"); ok {
+		return u, "ics"
+	}
+	return raw, "json"
+}
+
+func (s *server) handleBalance(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	user, format := userAndFormat(ps.ByName("user"))
+
+	report, err := s.reportFor(req.Context(), user)
+	if err != nil {
+		log.Printf("balance %s: %v", user, err)
+		http.Error(w, "failed to compute balance", http.StatusBadGateway)
+		return
+	}
+
+	if format == "ics" {
+		body, err := report.ICSFeed(time.Now())
+		if err != nil {
+			log.Printf("balance %s: rendering ics: %v", user, err)
+			http.Error(w, "failed to render balance", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Accrued float64   `json:"accrued"`
+		Spent   float64   `json:"spent"`
+		Balance float64   `json:"balance"`
+		FTE     float64   `json:"fte"`
+		AsOf    time.Time `json:"asOf"`
+	}{report.Accrued(), report.Spent(), report.Balance(), report.FTE, report.AsOf})
+}
+
+func (s *server) handleFreeBusy(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	user := ps.ByName("user")
+
+	start, end, err := parseFreeBusyRange(req.URL.Query().Get("start"), req.URL.Query().Get("end"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.reportFor(req.Context(), user)
+	if err != nil {
+		log.Printf("freebusy %s: %v", user, err)
+		http.Error(w, "failed to compute balance", http.StatusBadGateway)
+		return
+	}
+
+	body, err := report.FreeBusyICS(start, end, time.Now())
+	if err != nil {
+		log.Printf("freebusy %s: rendering freebusy: %v", user, err)
+		http.Error(w, "failed to render free/busy", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(body)
+}
+
+// parseFreeBusyRange parses the ?start=&end= query parameters (YYYY-MM-DD),
+// defaulting to the current calendar year when omitted.
+func parseFreeBusyRange(start, end string) (time.Time, time.Time, error) {
+	now := time.Now()
+	lo := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	hi := time.Date(now.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if start != "" {
+		t, err := time.Parse("2006-01-02", start)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		lo = t
+	}
+	if end != "" {
+		t, err := time.Parse("2006-01-02", end)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		hi = t
+	}
+	return lo, hi, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lvdlvd/holidaybalance/calc"
+)
+
+// readTeamFile reads one calendar name per line from path, ignoring blank
+// lines and lines starting with '#'.
+func readTeamFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+	return users, nil
+}
+
+// teamOptions collects the -format/-out/-concurrency/-warn-*/-slack-webhook
+// and -smtp-* flags that only apply to team mode.
+type teamOptions struct {
+	format      string
+	out         string
+	concurrency int
+
+	warnBelow, warnAbove float64
+	slackWebhook         string
+
+	smtpAddr, smtpFrom, smtpTo, smtpUser, smtpPassword string
+}
+
+// runTeam computes a consolidated report for users, writes it to
+// opts.out in opts.format, and dispatches a Slack and/or email digest for
+// any balance crossing opts.warnBelow/opts.warnAbove.
+func runTeam(cfg *calc.Config, users []string, opts teamOptions) error {
+	now := time.Now()
+	eoy := time.Date(now.Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+	rows := calc.ComputeBatch(context.Background(), cfg, users, opts.concurrency, eoy)
+
+	w := os.Stdout
+	if opts.out != "-" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	switch opts.format {
+	case "csv":
+		err = calc.WriteCSV(w, rows)
+	case "json":
+		err = calc.WriteJSON(w, rows)
+	case "html":
+		err = calc.WriteHTML(w, rows)
+	default:
+		return fmt.Errorf("unknown -format %q: want csv, json, or html", opts.format)
+	}
+	if err != nil {
+		return fmt.Errorf("writing team report: %w", err)
+	}
+
+	warnings := calc.Warnings(rows, opts.warnBelow, opts.warnAbove)
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	if opts.slackWebhook != "" {
+		if err := calc.SlackDigest(opts.slackWebhook, warnings); err != nil {
+			return fmt.Errorf("slack digest: %w", err)
+		}
+	}
+	if opts.smtpAddr != "" {
+		var to []string
+		for _, a := range strings.Split(opts.smtpTo, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				to = append(to, a)
+			}
+		}
+		if err := calc.EmailDigest(opts.smtpAddr, opts.smtpFrom, opts.smtpUser, opts.smtpPassword, to, warnings); err != nil {
+			return fmt.Errorf("email digest: %w", err)
+		}
+	}
+
+	return nil
+}